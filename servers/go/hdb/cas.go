@@ -0,0 +1,53 @@
+package hdb
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/awnumar/memguard"
+)
+
+// PutCAS stores value keyed by its SHA-256 hash, deduplicating identical
+// values across the store, and returns that hash. PutCAS always hashes
+// with SHA-256 regardless of Options.Hasher, since kv_cas's address space
+// and the secure key-lookup path are deliberately kept separate: content
+// addresses need to be stable across a db's lifetime even if its
+// KeyHasher is ever reconfigured. If value is already present, PutCAS is
+// a no-op and returns the existing hash.
+func (h *HareDB) PutCAS(value []byte) ([]byte, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	sum := sha256.Sum256(value)
+	if _, ok := h.kv_cas[sum]; ok {
+		return sum[:], nil
+	}
+
+	h.kv_cas[sum] = *memguard.NewEnclave(value)
+	return sum[:], nil
+}
+
+// GetCAS returns the value previously stored under hash by PutCAS.
+func (h *HareDB) GetCAS(hash []byte) ([]byte, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	if len(hash) != sha256.Size {
+		return nil, fmt.Errorf("hdb: CAS hash must be %d bytes, got %d", sha256.Size, len(hash))
+	}
+	var sum [32]byte
+	copy(sum[:], hash)
+
+	enc, ok := h.kv_cas[sum]
+	if !ok {
+		return nil, fmt.Errorf("hdb: no content stored under hash %x", hash)
+	}
+
+	buf, err := enc.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer buf.Destroy()
+
+	return append([]byte(nil), buf.Bytes()...), nil
+}
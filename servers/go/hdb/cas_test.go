@@ -0,0 +1,40 @@
+package hdb
+
+import "testing"
+
+func TestPutCASDeduplicates(t *testing.T) {
+	h := New()
+	defer h.Close()
+
+	hash1, err := h.PutCAS([]byte("same content"))
+	if err != nil {
+		t.Fatalf("PutCAS: %v", err)
+	}
+	hash2, err := h.PutCAS([]byte("same content"))
+	if err != nil {
+		t.Fatalf("PutCAS: %v", err)
+	}
+	if string(hash1) != string(hash2) {
+		t.Fatalf("PutCAS of identical content produced different hashes: %x vs %x", hash1, hash2)
+	}
+	if len(h.kv_cas) != 1 {
+		t.Fatalf("expected a single deduplicated entry, got %d", len(h.kv_cas))
+	}
+
+	got, err := h.GetCAS(hash1)
+	if err != nil {
+		t.Fatalf("GetCAS: %v", err)
+	}
+	if string(got) != "same content" {
+		t.Fatalf("got %q, want %q", got, "same content")
+	}
+}
+
+func TestGetCASMissing(t *testing.T) {
+	h := New()
+	defer h.Close()
+
+	if _, err := h.GetCAS(make([]byte, 32)); err == nil {
+		t.Fatalf("expected an error for a hash with no stored content")
+	}
+}
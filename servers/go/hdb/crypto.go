@@ -0,0 +1,69 @@
+package hdb
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+)
+
+// errShortCiphertext is returned when a record's ciphertext is too short
+// to contain even a GCM nonce, which only happens if the data file is
+// corrupt or was truncated mid-write.
+var errShortCiphertext = errors.New("hdb: ciphertext shorter than nonce")
+
+// deriveAESKey stretches the raw master secret into a 32-byte AES-256 key
+// via HKDF-SHA256, so the secret held in HareDB.sk is never fed to AES
+// directly and a leaked AES key never reveals the original secret bytes.
+func deriveAESKey(secret []byte) ([]byte, error) {
+	kdf := hkdf.New(sha256.New, secret, nil, []byte("haredb-aes-gcm-v1"))
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(kdf, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// encryptRecord seals plaintext with AES-GCM under key, prefixing the
+// random nonce to the returned ciphertext so decryptRecord is self
+// contained.
+func encryptRecord(key, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return gcm.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// decryptRecord reverses encryptRecord, reading the nonce back out of the
+// front of ciphertext.
+func decryptRecord(key, ciphertext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, errShortCiphertext
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, sealed, nil)
+}
@@ -0,0 +1,98 @@
+package hdb
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	sha256HasherID   byte = 1
+	sha512HasherID   byte = 2
+	blake2bHasherID  byte = 3
+	argon2idHasherID byte = 4
+)
+
+// KeyHasher hashes a plaintext key into the digest HareDB uses both as
+// the on-disk index lookup key and as the HMAC key protecting that
+// record. Implementations trade off lookup speed against resistance to
+// brute-forcing of user-supplied keys; see SHA256Hasher, SHA512Hasher,
+// BLAKE2bHasher and Argon2idHasher.
+//
+// The chosen hasher's ID is recorded in the store's header file the first
+// time it's opened, so later opens pick the same algorithm back up even
+// if the caller doesn't pass Options.Hasher again.
+type KeyHasher interface {
+	// Hash returns a digest of plaintext salted with salt. It must be
+	// deterministic: the same (plaintext, salt) pair always yields the
+	// same digest, since HareDB uses it to find existing records.
+	Hash(plaintext []byte, salt []byte) []byte
+
+	// ID is a single byte identifying the algorithm, persisted in the
+	// store header.
+	ID() byte
+}
+
+// SHA256Hasher is the default KeyHasher: fast, suitable for keys that are
+// already high entropy or don't need brute-force resistance.
+type SHA256Hasher struct{}
+
+func (SHA256Hasher) Hash(plaintext, salt []byte) []byte {
+	h := sha256.New()
+	h.Write(salt)
+	h.Write(plaintext)
+	return h.Sum(nil)
+}
+
+func (SHA256Hasher) ID() byte { return sha256HasherID }
+
+// SHA512Hasher is SHA256Hasher's wider sibling, for callers who want a
+// larger digest without changing algorithm family.
+type SHA512Hasher struct{}
+
+func (SHA512Hasher) Hash(plaintext, salt []byte) []byte {
+	h := sha512.New()
+	h.Write(salt)
+	h.Write(plaintext)
+	return h.Sum(nil)
+}
+
+func (SHA512Hasher) ID() byte { return sha512HasherID }
+
+// BLAKE2bHasher hashes keys with BLAKE2b-256, a faster alternative to the
+// SHA-2 family with the same general security profile.
+type BLAKE2bHasher struct{}
+
+func (BLAKE2bHasher) Hash(plaintext, salt []byte) []byte {
+	h, _ := blake2b.New256(nil)
+	h.Write(salt)
+	h.Write(plaintext)
+	return h.Sum(nil)
+}
+
+func (BLAKE2bHasher) ID() byte { return blake2bHasherID }
+
+// Argon2idHasher hashes keys with Argon2id, trading lookup speed for
+// resistance to offline brute-forcing. Unlike the other KeyHasher
+// implementations its cost is tunable; use DefaultArgon2idHasher for
+// reasonable interactive-use parameters.
+type Argon2idHasher struct {
+	Time    uint32
+	Memory  uint32 // KiB
+	Threads uint8
+	KeyLen  uint32
+}
+
+// DefaultArgon2idHasher returns an Argon2idHasher configured with
+// conservative parameters for interactive (as opposed to batch) lookups.
+func DefaultArgon2idHasher() Argon2idHasher {
+	return Argon2idHasher{Time: 1, Memory: 64 * 1024, Threads: 4, KeyLen: 32}
+}
+
+func (a Argon2idHasher) Hash(plaintext, salt []byte) []byte {
+	return argon2.IDKey(plaintext, salt, a.Time, a.Memory, a.Threads, a.KeyLen)
+}
+
+func (Argon2idHasher) ID() byte { return argon2idHasherID }
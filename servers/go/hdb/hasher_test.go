@@ -0,0 +1,68 @@
+package hdb
+
+import (
+	"os"
+	"testing"
+)
+
+func TestReopenWithCustomArgon2idParams(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("HAREDB_SECRET_KEY", "test-secret")
+
+	custom := Argon2idHasher{Time: 3, Memory: 19 * 1024, Threads: 2, KeyLen: 24}
+
+	h, err := Open(dir, &Options{CompactInterval: -1, Hasher: custom})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := h.Put("k", []byte("v")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// New() consumes and clears HAREDB_SECRET_KEY, so it must be set again
+	// before every Open that wants the secure path.
+	os.Setenv("HAREDB_SECRET_KEY", "test-secret")
+	h2, err := Open(dir, &Options{CompactInterval: -1, Hasher: custom})
+	if err != nil {
+		t.Fatalf("reopen with matching custom Argon2idHasher: %v", err)
+	}
+	defer h2.Close()
+
+	buf, err := h2.Get("k")
+	if err != nil {
+		t.Fatalf("Get after reopen: %v (record should not have been lost)", err)
+	}
+	defer buf.Destroy()
+	if string(buf.Bytes()) != "v" {
+		t.Fatalf("got %q, want %q", buf.Bytes(), "v")
+	}
+
+	info, err := os.Stat(dir + "/data.log")
+	if err != nil {
+		t.Fatalf("Stat data.log: %v", err)
+	}
+	if info.Size() == 0 {
+		t.Fatalf("data.log was truncated to 0 bytes on reopen")
+	}
+}
+
+func TestReopenWithMismatchedArgon2idParamsFails(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("HAREDB_SECRET_KEY", "test-secret")
+
+	original := Argon2idHasher{Time: 3, Memory: 19 * 1024, Threads: 2, KeyLen: 24}
+	h, err := Open(dir, &Options{CompactInterval: -1, Hasher: original})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	h.Close()
+
+	os.Setenv("HAREDB_SECRET_KEY", "test-secret")
+	different := Argon2idHasher{Time: 1, Memory: 64 * 1024, Threads: 4, KeyLen: 32}
+	if _, err := Open(dir, &Options{CompactInterval: -1, Hasher: different}); err == nil {
+		t.Fatalf("expected Open to reject a mismatched Argon2idHasher configuration")
+	}
+}
@@ -1,26 +1,236 @@
+// Package hdb implements HareDB, a small encrypted key/value store.
 package hdb
 
 import (
+	"fmt"
 	"os"
+	"sync"
 
 	"github.com/awnumar/memguard"
 )
 
+// kvEntry is one key/value pair held only in memory, in a db that has no
+// on-disk store (one created with New or RestoreFromMnemonic; an Open'd
+// db never uses kv_secure/kv_insecure): both the key and the value live sealed behind
+// their own memguard.Enclave, so a plaintext key never has to be kept
+// around in an ordinary Go value any longer than it takes to digest it.
+type kvEntry struct {
+	key   memguard.Enclave
+	value memguard.Enclave
+}
+
+// HareDB is a key/value store. Values written through the secure path are
+// protected at rest by a secret derived from HAREDB_SECRET_KEY; values
+// written through the insecure path are only locked in memory via
+// memguard and never encrypted. New creates a purely in-memory instance;
+// Open additionally backs it with an on-disk append-only log. Whether a
+// given Put is persisted to that log depends only on whether the db was
+// Open'd, not on whether it's in secure mode - encryption and
+// persistence are independent knobs.
+//
+// kv_secure and kv_insecure are keyed by a digest of the plaintext key
+// (see keyDigest). They only hold entries for a db that was never
+// Open'd; once h.store is set, Put/Get/Delete go through the on-disk log
+// instead.
 type HareDB struct {
-	sk          memguard.LockedBuffer
-	kv_secure   map[string]string
-	kv_insecure map[memguard.Enclave]memguard.Enclave
+	mu sync.RWMutex
+
+	sk          *memguard.LockedBuffer
+	useSecure   bool
+	kv_secure   map[string]kvEntry
+	kv_insecure map[string]kvEntry
+	kv_cas      map[[32]byte]memguard.Enclave
+
+	opts      Options
+	store     *store
+	closed    chan struct{}
+	compactWG sync.WaitGroup
+}
+
+// New returns a purely in-memory HareDB. If HAREDB_SECRET_KEY is set in
+// the environment, the db is in secure mode and Put keeps values in
+// kv_secure, otherwise in kv_insecure. Either way they only ever live
+// behind memguard's in-process protections: a db from New writes nothing
+// to disk, and only a db from Open encrypts values, as it appends them to
+// its log.
+func New() *HareDB {
+	unsafeSk := os.Getenv("HAREDB_SECRET_KEY")
+	os.Unsetenv("HAREDB_SECRET_KEY")
+
+	return &HareDB{
+		sk:          memguard.NewBufferFromBytes([]byte(unsafeSk)),
+		useSecure:   len(unsafeSk) > 0,
+		kv_secure:   make(map[string]kvEntry),
+		kv_insecure: make(map[string]kvEntry),
+		kv_cas:      make(map[[32]byte]memguard.Enclave),
+		closed:      make(chan struct{}),
+	}
+}
+
+// Open opens (creating if necessary) the HareDB log rooted at path and
+// replays it to rebuild an in-memory index, recovering from any torn
+// writes left by an unclean shutdown. opts may be nil to accept defaults.
+// Persistence applies regardless of whether HAREDB_SECRET_KEY is set;
+// the secret only controls whether records are AES-GCM encrypted before
+// they're appended to the log.
+func Open(path string, opts *Options) (*HareDB, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	h := New()
+	h.opts = *opts
+
+	hasher, err := resolveHasher(path, opts)
+	if err != nil {
+		return nil, fmt.Errorf("hdb: resolve hasher: %w", err)
+	}
+
+	s, err := openStore(path, hasher)
+	if err != nil {
+		return nil, fmt.Errorf("hdb: open store: %w", err)
+	}
+	h.store = s
+
+	if err := h.recover(); err != nil {
+		s.close()
+		return nil, fmt.Errorf("hdb: recover: %w", err)
+	}
+
+	h.compactWG.Add(1)
+	go h.compactLoop()
+
+	return h, nil
+}
+
+// keyDigest hashes key with the store's configured KeyHasher, or with
+// SHA256Hasher when h has no store (a pure in-memory db from New).
+func (h *HareDB) keyDigest(key []byte) []byte {
+	if h.store != nil {
+		return h.store.hash(key)
+	}
+	return SHA256Hasher{}.Hash(key, nil)
+}
+
+// Close stops any background compaction, waits for an in-flight Compact
+// to finish, and releases the underlying data file, if any. It is a
+// no-op beyond destroying the master secret for a db created with New.
+func (h *HareDB) Close() error {
+	close(h.closed)
+	h.compactWG.Wait()
+
+	h.mu.Lock()
+	h.sk.Destroy()
+	h.mu.Unlock()
+
+	if h.store == nil {
+		return nil
+	}
+	return h.store.close()
+}
+
+// Put stores value under key. When the db was opened with Open, the
+// write is always appended to the on-disk log - AES-GCM encrypted first
+// if HAREDB_SECRET_KEY was set at construction time, as plaintext
+// otherwise; a db created with New and never Open'd instead keeps value
+// only in memory behind a memguard.Enclave, in kv_secure or kv_insecure
+// depending on whether the db is in secure mode.
+func (h *HareDB) Put(key string, value []byte) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	kh := string(h.keyDigest([]byte(key)))
+
+	if h.store == nil {
+		entry := kvEntry{key: *memguard.NewEnclave([]byte(key)), value: *memguard.NewEnclave(value)}
+		if h.useSecure {
+			h.kv_secure[kh] = entry
+		} else {
+			h.kv_insecure[kh] = entry
+		}
+		return nil
+	}
+
+	onDisk := value
+	if h.useSecure {
+		aesKey, err := deriveAESKey(h.sk.Bytes())
+		if err != nil {
+			return err
+		}
+		onDisk, err = encryptRecord(aesKey, value)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err := h.store.append(record{key: []byte(key), value: onDisk})
+	return err
 }
 
-func New() HareDB {
+// Get looks up key and returns its value in a freshly allocated
+// memguard.LockedBuffer that the caller owns and must Destroy. This works
+// for both the secure and insecure paths, and for a db created with New
+// that was never backed by Open.
+func (h *HareDB) Get(key string) (*memguard.LockedBuffer, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
 
-	unsafe_sk := os.Getenv("HAREDB_SECRET_KEY")
-	use_secure := len(unsafe_sk) > 0
+	kh := string(h.keyDigest([]byte(key)))
 
-	hdb := HareDB{
-		*memguard.NewBuffer(len(unsafe_sk)),
-		make(map[string]string),
-		make(map[memguard.Enclave]memguard.Enclave),
+	if h.store == nil {
+		m := h.kv_insecure
+		if h.useSecure {
+			m = h.kv_secure
+		}
+		entry, ok := m[kh]
+		if !ok {
+			return nil, fmt.Errorf("hdb: key not found")
+		}
+		return entry.value.Open()
 	}
-	hdb.sk.Bytes()
+
+	r, ok, err := h.store.get(kh)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("hdb: key not found")
+	}
+
+	plain := r.value
+	if h.useSecure {
+		aesKey, err := deriveAESKey(h.sk.Bytes())
+		if err != nil {
+			return nil, err
+		}
+		plain, err = decryptRecord(aesKey, r.value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return memguard.NewBufferFromBytes(plain), nil
+}
+
+// Delete removes key. For a db backed by Open, this appends a tombstone
+// to the log so the deletion survives a restart until the next
+// compaction reclaims the space; otherwise it just drops key's entry
+// from the relevant in-memory map.
+func (h *HareDB) Delete(key string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	kh := string(h.keyDigest([]byte(key)))
+
+	if h.store == nil {
+		if h.useSecure {
+			delete(h.kv_secure, kh)
+		} else {
+			delete(h.kv_insecure, kh)
+		}
+		return nil
+	}
+
+	_, err := h.store.append(record{flags: tombstoneFlag, key: []byte(key)})
+	return err
 }
@@ -0,0 +1,96 @@
+package hdb
+
+import (
+	"os"
+	"testing"
+)
+
+// TestOpenPersistsWithoutSecretKey guards against a regression where Put
+// checked useSecure before h.store, so an Open'd db with no
+// HAREDB_SECRET_KEY set silently kept writes in memory only instead of
+// appending them (unencrypted) to the on-disk log.
+func TestOpenPersistsWithoutSecretKey(t *testing.T) {
+	os.Unsetenv("HAREDB_SECRET_KEY")
+	dir := t.TempDir()
+
+	h, err := Open(dir, &Options{CompactInterval: -1})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := h.Put("foo", []byte("bar")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if err := h.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	info, err := os.Stat(dir + "/data.log")
+	if err != nil {
+		t.Fatalf("Stat data.log: %v", err)
+	}
+	if info.Size() == 0 {
+		t.Fatalf("data.log is empty; insecure Put was not persisted")
+	}
+
+	h2, err := Open(dir, &Options{CompactInterval: -1})
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer h2.Close()
+
+	buf, err := h2.Get("foo")
+	if err != nil {
+		t.Fatalf("Get after reopen: %v", err)
+	}
+	defer buf.Destroy()
+	if string(buf.Bytes()) != "bar" {
+		t.Fatalf("got %q, want %q", buf.Bytes(), "bar")
+	}
+}
+
+func TestGetSecureWithoutOpen(t *testing.T) {
+	os.Setenv("HAREDB_SECRET_KEY", "test-secret")
+	h := New()
+	defer h.Close()
+
+	if err := h.Put("foo", []byte("bar")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	buf, err := h.Get("foo")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer buf.Destroy()
+
+	if string(buf.Bytes()) != "bar" {
+		t.Fatalf("got %q, want %q", buf.Bytes(), "bar")
+	}
+
+	if err := h.Delete("foo"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := h.Get("foo"); err == nil {
+		t.Fatalf("expected key to be gone after Delete")
+	}
+}
+
+func TestGetInsecureWithoutOpen(t *testing.T) {
+	os.Unsetenv("HAREDB_SECRET_KEY")
+	h := New()
+	defer h.Close()
+
+	if err := h.Put("foo", []byte("bar")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	buf, err := h.Get("foo")
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	defer buf.Destroy()
+
+	if string(buf.Bytes()) != "bar" {
+		t.Fatalf("got %q, want %q", buf.Bytes(), "bar")
+	}
+}
@@ -0,0 +1,106 @@
+package hdb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+)
+
+const headerFileName = "header"
+
+// argon2idHeaderLen is the encoded size, in bytes, of an Argon2idHasher's
+// parameters: Time(4) + Memory(4) + Threads(1) + KeyLen(4).
+const argon2idHeaderLen = 4 + 4 + 1 + 4
+
+// encodeHeader serializes hasher's ID and, for Argon2idHasher, its full
+// parameter set, so a store created with a non-default Argon2id
+// configuration can be reopened without those parameters having to be
+// passed in again - and so it can't silently be reopened with the wrong
+// ones.
+func encodeHeader(hasher KeyHasher) []byte {
+	buf := []byte{hasher.ID()}
+
+	a, ok := hasher.(Argon2idHasher)
+	if !ok {
+		return buf
+	}
+
+	var tmp [4]byte
+	binary.BigEndian.PutUint32(tmp[:], a.Time)
+	buf = append(buf, tmp[:]...)
+	binary.BigEndian.PutUint32(tmp[:], a.Memory)
+	buf = append(buf, tmp[:]...)
+	buf = append(buf, a.Threads)
+	binary.BigEndian.PutUint32(tmp[:], a.KeyLen)
+	buf = append(buf, tmp[:]...)
+	return buf
+}
+
+// decodeHeader is encodeHeader's inverse.
+func decodeHeader(data []byte) (KeyHasher, error) {
+	if len(data) < 1 {
+		return nil, fmt.Errorf("hdb: header is empty")
+	}
+
+	switch id := data[0]; id {
+	case sha256HasherID:
+		return SHA256Hasher{}, nil
+	case sha512HasherID:
+		return SHA512Hasher{}, nil
+	case blake2bHasherID:
+		return BLAKE2bHasher{}, nil
+	case argon2idHasherID:
+		if len(data) < 1+argon2idHeaderLen {
+			return nil, fmt.Errorf("hdb: argon2id header is truncated")
+		}
+		return Argon2idHasher{
+			Time:    binary.BigEndian.Uint32(data[1:5]),
+			Memory:  binary.BigEndian.Uint32(data[5:9]),
+			Threads: data[9],
+			KeyLen:  binary.BigEndian.Uint32(data[10:14]),
+		}, nil
+	default:
+		return nil, fmt.Errorf("hdb: unknown hasher id %d in header", id)
+	}
+}
+
+// resolveHasher figures out which KeyHasher a store at dir should use: if
+// the store already has a header, the hasher (including its full
+// parameters, for Argon2id) recorded there wins, and is checked for an
+// exact match against opts.Hasher if one was given; otherwise opts.Hasher
+// is used, falling back to SHA256Hasher, and a new header is written so
+// later opens agree.
+func resolveHasher(dir string, opts *Options) (KeyHasher, error) {
+	path := filepath.Join(dir, headerFileName)
+
+	existing, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		stored, err := decodeHeader(existing)
+		if err != nil {
+			return nil, fmt.Errorf("hdb: header file %s: %w", path, err)
+		}
+		if opts.Hasher != nil && !reflect.DeepEqual(opts.Hasher, stored) {
+			return nil, fmt.Errorf("hdb: store at %s was created with a different KeyHasher configuration than the one passed to Open", dir)
+		}
+		return stored, nil
+
+	case os.IsNotExist(err):
+		hasher := opts.Hasher
+		if hasher == nil {
+			hasher = SHA256Hasher{}
+		}
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, encodeHeader(hasher), 0o600); err != nil {
+			return nil, err
+		}
+		return hasher, nil
+
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,131 @@
+package hdb
+
+import (
+	"io"
+
+	"github.com/awnumar/memguard"
+)
+
+// Iterator enumerates a HareDB's key/value pairs, decrypting or opening
+// each entry into freshly allocated memguard.LockedBuffers as it's
+// visited. For a db backed by Open, it takes a snapshot of the offsets
+// present in the store's index at construction time, so concurrent
+// Put/Delete calls against the db don't invalidate an iteration already
+// in progress; for a purely in-memory db from New, it instead snapshots
+// the entries in kv_secure or kv_insecure, whichever is active. Entries
+// that fail their HMAC check, fail to decrypt, or whose enclave fails to
+// open are skipped rather than surfaced as an error; see Skipped.
+type Iterator struct {
+	h       *HareDB
+	offsets []int64
+	entries []kvEntry
+	pos     int
+	skipped int
+}
+
+// Iter returns an Iterator over h's stored pairs as of the moment Iter is
+// called.
+func (h *HareDB) Iter() *Iterator {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	it := &Iterator{h: h}
+	if h.store != nil {
+		it.offsets = h.store.snapshot()
+		return it
+	}
+
+	m := h.kv_insecure
+	if h.useSecure {
+		m = h.kv_secure
+	}
+	it.entries = make([]kvEntry, 0, len(m))
+	for _, e := range m {
+		it.entries = append(it.entries, e)
+	}
+	return it
+}
+
+// Next decrypts and returns the next key/value pair. Both buffers are
+// freshly allocated and owned by the caller, who is responsible for
+// destroying them. Next returns io.EOF once the snapshot taken by Iter is
+// exhausted.
+func (it *Iterator) Next() (key, value *memguard.LockedBuffer, err error) {
+	it.h.mu.RLock()
+	defer it.h.mu.RUnlock()
+
+	if it.h.store != nil {
+		return it.nextFromStore()
+	}
+	return it.nextFromMemory()
+}
+
+func (it *Iterator) nextFromStore() (key, value *memguard.LockedBuffer, err error) {
+	for it.pos < len(it.offsets) {
+		off := it.offsets[it.pos]
+		it.pos++
+
+		r, err := it.h.store.readAtSync(off)
+		if err != nil {
+			it.skipped++
+			continue
+		}
+
+		plain := r.value
+		if it.h.useSecure {
+			aesKey, kerr := deriveAESKey(it.h.sk.Bytes())
+			if kerr != nil {
+				it.skipped++
+				continue
+			}
+			plain, err = decryptRecord(aesKey, r.value)
+			if err != nil {
+				it.skipped++
+				continue
+			}
+		}
+
+		return memguard.NewBufferFromBytes(r.key), memguard.NewBufferFromBytes(plain), nil
+	}
+
+	return nil, nil, io.EOF
+}
+
+func (it *Iterator) nextFromMemory() (key, value *memguard.LockedBuffer, err error) {
+	for it.pos < len(it.entries) {
+		e := it.entries[it.pos]
+		it.pos++
+
+		keyBuf, err := e.key.Open()
+		if err != nil {
+			it.skipped++
+			continue
+		}
+		valueBuf, err := e.value.Open()
+		if err != nil {
+			keyBuf.Destroy()
+			it.skipped++
+			continue
+		}
+
+		return keyBuf, valueBuf, nil
+	}
+
+	return nil, nil, io.EOF
+}
+
+// Skipped returns the number of entries Next has skipped so far because
+// their record failed its HMAC check, failed to decrypt, or had an
+// enclave that failed to open.
+func (it *Iterator) Skipped() int {
+	return it.skipped
+}
+
+// Close releases the iterator's snapshot. It is always safe to call and
+// never returns an error; callers can defer it alongside the Destroy
+// calls on the buffers Next returns.
+func (it *Iterator) Close() error {
+	it.offsets = nil
+	it.entries = nil
+	return nil
+}
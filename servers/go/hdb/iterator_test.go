@@ -0,0 +1,137 @@
+package hdb
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestIteratorVisitsAllPairs(t *testing.T) {
+	os.Setenv("HAREDB_SECRET_KEY", "test-secret")
+	h, err := Open(t.TempDir(), &Options{CompactInterval: -1})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer h.Close()
+
+	want := map[string]string{"a": "1", "b": "2", "c": "3"}
+	for k, v := range want {
+		if err := h.Put(k, []byte(v)); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	got := map[string]string{}
+	it := h.Iter()
+	defer it.Close()
+	for {
+		key, value, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got[string(key.Bytes())] = string(value.Bytes())
+		key.Destroy()
+		value.Destroy()
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d pairs, want %d: %v", len(got), len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("key %q: got %q, want %q", k, got[k], v)
+		}
+	}
+	if it.Skipped() != 0 {
+		t.Fatalf("expected 0 skipped entries, got %d", it.Skipped())
+	}
+}
+
+func TestIteratorVisitsInMemoryPairs(t *testing.T) {
+	os.Setenv("HAREDB_SECRET_KEY", "test-secret")
+	h := New()
+	defer h.Close()
+
+	want := map[string]string{"a": "1", "b": "2", "c": "3"}
+	for k, v := range want {
+		if err := h.Put(k, []byte(v)); err != nil {
+			t.Fatalf("Put: %v", err)
+		}
+	}
+
+	got := map[string]string{}
+	it := h.Iter()
+	defer it.Close()
+	for {
+		key, value, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		got[string(key.Bytes())] = string(value.Bytes())
+		key.Destroy()
+		value.Destroy()
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d pairs, want %d: %v", len(got), len(want), got)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Fatalf("key %q: got %q, want %q", k, got[k], v)
+		}
+	}
+	if it.Skipped() != 0 {
+		t.Fatalf("expected 0 skipped entries, got %d", it.Skipped())
+	}
+}
+
+func TestIteratorSkipsCorruptRecords(t *testing.T) {
+	dir := t.TempDir()
+	os.Setenv("HAREDB_SECRET_KEY", "test-secret")
+	h, err := Open(dir, &Options{CompactInterval: -1})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer h.Close()
+
+	if err := h.Put("good", []byte("value")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Fabricate a bogus index entry pointing at an offset that doesn't
+	// hold a valid record, simulating a corrupted entry without needing
+	// to tamper with the file on disk.
+	h.store.mu.Lock()
+	h.store.index["not-a-real-digest"] = 999999
+	h.store.mu.Unlock()
+
+	it := h.Iter()
+	defer it.Close()
+
+	count := 0
+	for {
+		key, value, err := it.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		count++
+		key.Destroy()
+		value.Destroy()
+	}
+
+	if count != 1 {
+		t.Fatalf("expected 1 valid entry, got %d", count)
+	}
+	if it.Skipped() != 1 {
+		t.Fatalf("expected 1 skipped entry, got %d", it.Skipped())
+	}
+}
@@ -0,0 +1,155 @@
+package hdb
+
+import (
+	_ "embed"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/awnumar/memguard"
+)
+
+//go:embed wordlist_english.txt
+var wordlistRaw string
+
+// wordlist is HareDB's fixed mnemonic dictionary: 2048 words, one per
+// 11-bit index, checked into the repo so a mnemonic produced by one
+// HareDB version can always be restored by another.
+var wordlist []string
+
+var wordIndex map[string]int
+
+func init() {
+	wordlist = strings.Split(strings.TrimSpace(wordlistRaw), "\n")
+	wordIndex = make(map[string]int, len(wordlist))
+	for i, w := range wordlist {
+		wordIndex[w] = i
+	}
+}
+
+// checksumWord derives the 11-bit checksum word for entropy from its
+// SHA-256 digest.
+func checksumWord(entropy []byte) int {
+	sum := sha256.Sum256(entropy)
+	return (int(sum[0])<<8 | int(sum[1])) & 0x7FF
+}
+
+// bytesToWords11 packs data into a slice of 11-bit values, zero-padding
+// the final group if data's bit length isn't a multiple of 11.
+func bytesToWords11(data []byte) []int {
+	var bits strings.Builder
+	for _, b := range data {
+		fmt.Fprintf(&bits, "%08b", b)
+	}
+	s := bits.String()
+	if pad := len(s) % 11; pad != 0 {
+		s += strings.Repeat("0", 11-pad)
+	}
+
+	words := make([]int, 0, len(s)/11)
+	for i := 0; i < len(s); i += 11 {
+		v, _ := strconv.ParseUint(s[i:i+11], 2, 32)
+		words = append(words, int(v))
+	}
+	return words
+}
+
+// words11ToBytes reverses bytesToWords11, truncating back to byteLen
+// bytes and discarding the zero padding bytesToWords11 added.
+func words11ToBytes(words []int, byteLen int) []byte {
+	var bits strings.Builder
+	for _, w := range words {
+		fmt.Fprintf(&bits, "%011b", w)
+	}
+	s := bits.String()
+
+	out := make([]byte, byteLen)
+	for i := 0; i < byteLen; i++ {
+		v, _ := strconv.ParseUint(s[i*8:i*8+8], 2, 8)
+		out[i] = byte(v)
+	}
+	return out
+}
+
+// BackupMnemonic encodes the database's master secret as a phrase from
+// HareDB's wordlist, following BIP39's 11-bit word indices and trailing
+// checksum word, adapted for a variable-length secret rather than BIP39's
+// fixed entropy sizes: the first word records the secret's byte length,
+// the middle words carry its bits, and the final word is a checksum
+// derived from SHA-256 of the secret so a mistyped or reordered word is
+// caught on restore. The secret is copied out of h.sk into a scratch
+// buffer only for the duration of this call, and that scratch buffer is
+// wiped before BackupMnemonic returns.
+func (h *HareDB) BackupMnemonic() (string, error) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	secret := h.sk.Bytes()
+	if len(secret) == 0 {
+		return "", errors.New("hdb: no secret key to back up")
+	}
+	if len(secret) >= len(wordlist) {
+		return "", fmt.Errorf("hdb: secret is too long to encode as a mnemonic (%d bytes)", len(secret))
+	}
+
+	scratch := make([]byte, len(secret))
+	copy(scratch, secret)
+	defer memguard.WipeBytes(scratch)
+
+	words := make([]string, 0, 2+((len(scratch)*8+10)/11))
+	words = append(words, wordlist[len(scratch)])
+	for _, idx := range bytesToWords11(scratch) {
+		words = append(words, wordlist[idx])
+	}
+	words = append(words, wordlist[checksumWord(scratch)])
+
+	return strings.Join(words, " "), nil
+}
+
+// RestoreFromMnemonic reverses BackupMnemonic, returning a fresh, purely
+// in-memory HareDB whose secret is the decoded entropy. The decoded bytes
+// only ever exist in a scratch slice that is wiped before this function
+// returns; the buffer backing the returned HareDB is a separate
+// memguard-allocated copy.
+func RestoreFromMnemonic(words string) (*HareDB, error) {
+	fields := strings.Fields(words)
+	if len(fields) < 3 {
+		return nil, errors.New("hdb: mnemonic must have a length word, at least one data word, and a checksum word")
+	}
+
+	indices := make([]int, len(fields))
+	for i, w := range fields {
+		idx, ok := wordIndex[w]
+		if !ok {
+			return nil, fmt.Errorf("hdb: %q is not in the HareDB wordlist", w)
+		}
+		indices[i] = idx
+	}
+
+	byteLen := indices[0]
+	dataWords := indices[1 : len(indices)-1]
+	gotChecksum := indices[len(indices)-1]
+
+	wantDataWords := (byteLen*8 + 10) / 11
+	if len(dataWords) != wantDataWords {
+		return nil, fmt.Errorf("hdb: mnemonic has %d data words, expected %d for a %d-byte secret", len(dataWords), wantDataWords, byteLen)
+	}
+
+	entropy := words11ToBytes(dataWords, byteLen)
+	defer memguard.WipeBytes(entropy)
+
+	if got := checksumWord(entropy); got != gotChecksum {
+		return nil, errors.New("hdb: mnemonic checksum mismatch, a word may be wrong or out of order")
+	}
+
+	return &HareDB{
+		sk:          memguard.NewBufferFromBytes(entropy),
+		useSecure:   true,
+		kv_secure:   make(map[string]kvEntry),
+		kv_insecure: make(map[string]kvEntry),
+		kv_cas:      make(map[[32]byte]memguard.Enclave),
+		closed:      make(chan struct{}),
+	}, nil
+}
@@ -0,0 +1,56 @@
+package hdb
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMnemonicRoundTrip(t *testing.T) {
+	os.Setenv("HAREDB_SECRET_KEY", "correct horse battery staple")
+	h := New()
+	defer h.Close()
+
+	phrase, err := h.BackupMnemonic()
+	if err != nil {
+		t.Fatalf("BackupMnemonic: %v", err)
+	}
+
+	words := strings.Fields(phrase)
+	if len(words) < 3 {
+		t.Fatalf("expected at least a length word, a data word, and a checksum word, got %d", len(words))
+	}
+
+	restored, err := RestoreFromMnemonic(phrase)
+	if err != nil {
+		t.Fatalf("RestoreFromMnemonic: %v", err)
+	}
+	defer restored.Close()
+
+	if string(restored.sk.Bytes()) != "correct horse battery staple" {
+		t.Fatalf("restored secret = %q, want %q", restored.sk.Bytes(), "correct horse battery staple")
+	}
+}
+
+func TestMnemonicRejectsBadChecksum(t *testing.T) {
+	os.Setenv("HAREDB_SECRET_KEY", "another-secret")
+	h := New()
+	defer h.Close()
+
+	phrase, err := h.BackupMnemonic()
+	if err != nil {
+		t.Fatalf("BackupMnemonic: %v", err)
+	}
+
+	words := strings.Fields(phrase)
+	last := words[len(words)-1]
+	replacement := wordlist[0]
+	if last == replacement {
+		replacement = wordlist[1]
+	}
+	words[len(words)-1] = replacement
+
+	if _, err := RestoreFromMnemonic(strings.Join(words, " ")); err == nil {
+		t.Fatalf("expected a corrupted checksum word to be rejected")
+	}
+}
@@ -0,0 +1,28 @@
+package hdb
+
+import "time"
+
+// Options configures a database opened with Open. The zero value is a
+// usable set of defaults.
+type Options struct {
+	// CompactInterval controls how often the data file is scanned for
+	// tombstoned and overwritten records so they can be reclaimed. The
+	// zero value uses defaultCompactInterval; a negative value disables
+	// background compaction, leaving callers to trigger it manually via
+	// Compact.
+	CompactInterval time.Duration
+
+	// Hasher selects the KeyHasher used to turn plaintext keys into the
+	// digests stored in the on-disk index and used as HMAC keys. It only
+	// takes effect the first time a store is created at a given path;
+	// reopening an existing store always uses the exact hasher recorded in
+	// its header - including, for Argon2idHasher, its Time/Memory/Threads/
+	// KeyLen parameters, not just its algorithm - and Open returns an
+	// error if Hasher is set to anything that doesn't match that exactly.
+	Hasher KeyHasher
+}
+
+// defaultCompactInterval is used when Options.CompactInterval is left at
+// its zero value but the caller hasn't explicitly disabled compaction by
+// passing a negative duration.
+const defaultCompactInterval = 5 * time.Minute
@@ -0,0 +1,357 @@
+package hdb
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// recordMagic marks the start of every record in the data file, letting
+// recover() tell a real record apart from a torn write left by a crash.
+const recordMagic uint32 = 0x48415245 // "HARE"
+
+const tombstoneFlag uint8 = 1 << 0
+
+// record is a single entry in the append-only data file:
+//
+//	magic   uint32
+//	flags   uint8
+//	keyLen  uint32
+//	valLen  uint32
+//	key     []byte
+//	value   []byte            (AES-GCM ciphertext when encryption is enabled)
+//	hmac    [32]byte           (over everything above, keyed with the store's KeyHasher digest)
+//
+// The trailing HMAC lets recover() detect bit rot and torn writes without
+// needing a separate checksum file.
+type record struct {
+	flags uint8
+	key   []byte
+	value []byte
+}
+
+// store is the on-disk append-only log plus the in-memory index that
+// accelerates lookups. The index maps a hasher digest of a key (see
+// KeyHasher) to the byte offset of that key's most recent record in the
+// data file; the log itself remains the source of truth and is what
+// recover rebuilds the index from after a crash.
+type store struct {
+	mu       sync.Mutex
+	dir      string
+	hasher   KeyHasher
+	dataFile *os.File
+	index    map[string]int64
+	tomb     map[string]bool
+}
+
+// hash digests key with the store's configured KeyHasher. Index lookups
+// never use a per-key salt: the salt parameter exists on KeyHasher for
+// callers like Options.Hasher's Argon2id use that want one, but HareDB
+// itself only needs the digest to be stable across opens.
+func (s *store) hash(key []byte) []byte {
+	return s.hasher.Hash(key, nil)
+}
+
+func openStore(dir string, hasher KeyHasher) (*store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, "data.log"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &store{
+		dir:      dir,
+		hasher:   hasher,
+		dataFile: f,
+		index:    make(map[string]int64),
+		tomb:     make(map[string]bool),
+	}, nil
+}
+
+// close releases the data file. It takes s.mu so it can't race with
+// Compact swapping s.dataFile out for the freshly-rewritten file.
+func (s *store) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dataFile.Close()
+}
+
+// get looks up kh in the index and decodes the record at that offset, if
+// any. It holds s.mu for the whole lookup-plus-read so it can never race
+// with Compact swapping s.index or s.dataFile out from under it.
+func (s *store) get(kh string) (record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	off, ok := s.index[kh]
+	if !ok {
+		return record{}, false, nil
+	}
+
+	r, err := s.readAt(off)
+	return r, true, err
+}
+
+// snapshot returns the offsets currently in the index, for callers (e.g.
+// Iterator) that want to read every record without holding s.mu for the
+// whole iteration.
+func (s *store) snapshot() []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	offsets := make([]int64, 0, len(s.index))
+	for _, off := range s.index {
+		offsets = append(offsets, off)
+	}
+	return offsets
+}
+
+// readAtSync is readAt guarded by s.mu, for callers outside the store
+// that don't already hold it (Compact and get call readAt directly
+// since they take the lock themselves).
+func (s *store) readAtSync(off int64) (record, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.readAt(off)
+}
+
+// append writes r to the end of the data file and records its offset in
+// the index, returning the offset it was written at.
+func (s *store) append(r record) (int64, error) {
+	kh := s.hash(r.key)
+
+	buf := make([]byte, 0, 4+1+4+4+len(r.key)+len(r.value))
+	var tmp [4]byte
+	binary.BigEndian.PutUint32(tmp[:], recordMagic)
+	buf = append(buf, tmp[:]...)
+	buf = append(buf, r.flags)
+	binary.BigEndian.PutUint32(tmp[:], uint32(len(r.key)))
+	buf = append(buf, tmp[:]...)
+	binary.BigEndian.PutUint32(tmp[:], uint32(len(r.value)))
+	buf = append(buf, tmp[:]...)
+	buf = append(buf, r.key...)
+	buf = append(buf, r.value...)
+
+	mac := hmac.New(sha256.New, kh)
+	mac.Write(buf)
+	buf = mac.Sum(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	off, err := s.dataFile.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := s.dataFile.Write(buf); err != nil {
+		return 0, err
+	}
+	if err := s.dataFile.Sync(); err != nil {
+		return 0, err
+	}
+
+	khs := string(kh)
+	if r.flags&tombstoneFlag != 0 {
+		s.tomb[khs] = true
+		delete(s.index, khs)
+	} else {
+		delete(s.tomb, khs)
+		s.index[khs] = off
+	}
+
+	return off, nil
+}
+
+// readAt decodes the record stored at offset off.
+func (s *store) readAt(off int64) (record, error) {
+	header := make([]byte, 4+1+4+4)
+	if _, err := s.dataFile.ReadAt(header, off); err != nil {
+		return record{}, err
+	}
+
+	magic := binary.BigEndian.Uint32(header[0:4])
+	if magic != recordMagic {
+		return record{}, fmt.Errorf("hdb: bad record magic at offset %d", off)
+	}
+	flags := header[4]
+	keyLen := binary.BigEndian.Uint32(header[5:9])
+	valLen := binary.BigEndian.Uint32(header[9:13])
+
+	body := make([]byte, keyLen+valLen)
+	if _, err := s.dataFile.ReadAt(body, off+int64(len(header))); err != nil {
+		return record{}, err
+	}
+
+	wantMAC := make([]byte, 32)
+	if _, err := s.dataFile.ReadAt(wantMAC, off+int64(len(header))+int64(len(body))); err != nil {
+		return record{}, err
+	}
+
+	kh := s.hash(body[:keyLen])
+	mac := hmac.New(sha256.New, kh)
+	mac.Write(header)
+	mac.Write(body)
+	if !hmac.Equal(mac.Sum(nil), wantMAC) {
+		return record{}, errors.New("hdb: record failed HMAC validation")
+	}
+
+	return record{flags: flags, key: body[:keyLen], value: body[keyLen:]}, nil
+}
+
+// recover rebuilds the in-memory index by scanning the data file from the
+// start. Any record that fails its HMAC check, or that the file simply
+// doesn't have enough bytes left for, is treated as a torn write from an
+// unclean shutdown: the log is truncated at that point and the scan
+// stops. Everything from that record on is discarded, including any
+// later records that did pass their HMAC check.
+func (h *HareDB) recover() error {
+	s := h.store
+	off := int64(0)
+
+	for {
+		r, err := s.readAt(off)
+		if err != nil {
+			break
+		}
+
+		kh := string(s.hash(r.key))
+		if r.flags&tombstoneFlag != 0 {
+			s.tomb[kh] = true
+			delete(s.index, kh)
+		} else {
+			delete(s.tomb, kh)
+			s.index[kh] = off
+		}
+
+		off += 4 + 1 + 4 + 4 + int64(len(r.key)) + int64(len(r.value)) + 32
+	}
+
+	return s.dataFile.Truncate(off)
+}
+
+// compactLoop periodically rewrites the data file so that only the
+// latest, non-tombstoned record for each key survives, reclaiming the
+// space used by overwrites and deletes. It exits when h.closed is
+// signalled.
+func (h *HareDB) compactLoop() {
+	defer h.compactWG.Done()
+
+	interval := h.opts.CompactInterval
+	if interval == 0 {
+		interval = defaultCompactInterval
+	}
+	if interval < 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-h.closed:
+			return
+		case <-ticker.C:
+			if err := h.Compact(); err != nil {
+				continue
+			}
+		}
+	}
+}
+
+// Compact rewrites the data file keeping only the latest record for each
+// live key, dropping tombstones and stale overwrites in the process.
+func (h *HareDB) Compact() error {
+	s := h.store
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpPath := filepath.Join(s.dir, "data.log.compact")
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+
+	newIndex := make(map[string]int64, len(s.index))
+	for kh, off := range s.index {
+		r, err := s.readAt(off)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+
+		newOff, err := appendTo(tmp, s.hasher, r)
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmpPath)
+			return err
+		}
+		newIndex[kh] = newOff
+	}
+
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := s.dataFile.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(s.dir, "data.log")); err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.dir, "data.log"), os.O_RDWR, 0o600)
+	if err != nil {
+		return err
+	}
+	s.dataFile = f
+	s.index = newIndex
+	s.tomb = make(map[string]bool)
+	return nil
+}
+
+// appendTo encodes and writes r the same way store.append does, for
+// Compact, so compaction doesn't need a live *store to write through. It
+// does not fsync f or touch any index.
+func appendTo(f *os.File, hasher KeyHasher, r record) (int64, error) {
+	kh := hasher.Hash(r.key, nil)
+
+	buf := make([]byte, 0, 4+1+4+4+len(r.key)+len(r.value))
+	var tmp [4]byte
+	binary.BigEndian.PutUint32(tmp[:], recordMagic)
+	buf = append(buf, tmp[:]...)
+	buf = append(buf, r.flags)
+	binary.BigEndian.PutUint32(tmp[:], uint32(len(r.key)))
+	buf = append(buf, tmp[:]...)
+	binary.BigEndian.PutUint32(tmp[:], uint32(len(r.value)))
+	buf = append(buf, tmp[:]...)
+	buf = append(buf, r.key...)
+	buf = append(buf, r.value...)
+
+	mac := hmac.New(sha256.New, kh)
+	mac.Write(buf)
+	buf = mac.Sum(buf)
+
+	off, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := f.Write(buf); err != nil {
+		return 0, err
+	}
+	return off, nil
+}